@@ -0,0 +1,58 @@
+package dlpipe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"golang.org/x/time/rate"
+
+	"github.com/test-go/testify/require"
+)
+
+// TestLimitedWriterSplitsWritesLargerThanBurst guards against the limiter
+// returning "exceeds limiter's burst" for any single Write bigger than the
+// configured burst, which used to abort large single-shot writes (e.g. a
+// whole parallel-mode chunk) outright.
+func TestLimitedWriterSplitsWritesLargerThanBurst(t *testing.T) {
+	r := require.New(t)
+
+	const burst = 64
+	payload := bytes.Repeat([]byte{0xAB}, burst*5+7)
+
+	var dst bytes.Buffer
+	lw := &limitedWriter{
+		ctx:     context.Background(),
+		w:       &dst,
+		// A finite rate: with rate.Inf, WaitN never reports exceeding the
+		// burst, so an unsplit Write would pass unnoticed.
+		limiter: rate.NewLimiter(rate.Limit(1<<20), burst),
+	}
+
+	n, err := lw.Write(payload)
+	r.NoError(err)
+	r.Equal(len(payload), n)
+	r.Equal(payload, dst.Bytes())
+}
+
+// TestBandwidthLimitWithChunkSizeLargerThanLimit reproduces the reported
+// regression: WithConcurrency chunks bigger than the bytesPerSecond cap must
+// still succeed, since WithBandwidthLimit is documented to work together
+// with parallel chunked downloads.
+func TestBandwidthLimitWithChunkSizeLargerThanLimit(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	serverURL, expectedHash, cleanup := serveInterruptedTestFile(t, fileSize, 0)
+	defer cleanup()
+
+	hasher := sha256.New()
+	err := DownloadURL(ctx, serverURL, io.Discard,
+		WithConcurrency(2, fileSize), // a single chunk covering the whole file
+		WithBandwidthLimit(fileSize/4),
+		WithExpectedHash(hasher, expectedHash),
+	)
+	r.NoError(err)
+}
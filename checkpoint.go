@@ -0,0 +1,277 @@
+package dlpipe
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miolini/datacounter"
+)
+
+// checkpointByteInterval and checkpointTimeInterval are vars rather than
+// consts so tests can shrink them to keep crash/resume tests fast.
+var (
+	checkpointByteInterval uint64 = 8 * oneMB
+	checkpointTimeInterval        = 5 * time.Second
+)
+
+// WithCheckpoint persists download progress to a sidecar file at path so the
+// transfer can resume after a crash or Ctrl-C, not just within one process.
+// The destination passed to DownloadURL must be seekable (e.g. *os.File).
+func WithCheckpoint(path string) DownloadOpt {
+	return func(d *downloader) {
+		d.checkpointPath = path
+	}
+}
+
+// checkpointState is the sidecar file contents.
+type checkpointState struct {
+	URL           string `json:"url"`
+	ContentLength int64  `json:"content_length"`
+	ETag          string `json:"etag,omitempty"`
+	LastModified  string `json:"last_modified,omitempty"`
+	BytesWritten  uint64 `json:"bytes_written"`
+	HasherState   []byte `json:"hasher_state,omitempty"`
+}
+
+// checkpointingWriter wraps a writer, counts bytes from a (possibly nonzero,
+// resumed) offset, and persists a checkpoint every checkpointByteInterval
+// bytes, complementing the time-based trigger in checkpointLoop. mu
+// serializes every access to the wrapped writer (and, transitively, the
+// checkpoint hasher chained in front of it via WithHasher/WithExpectedHash)
+// against persistCheckpoint's hasher.MarshalBinary call, so Write and the
+// ticker-driven checkIn never touch the hasher at the same time.
+type checkpointingWriter struct {
+	d  *downloader
+	w  io.Writer
+	mu sync.Mutex
+
+	total      uint64
+	sinceBytes uint64
+}
+
+func newCheckpointingWriter(d *downloader, w io.Writer, resumeBytes uint64) *checkpointingWriter {
+	return &checkpointingWriter{d: d, w: w, total: resumeBytes}
+}
+
+func (cw *checkpointingWriter) Write(p []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	n, err := cw.w.Write(p)
+	cw.total += uint64(n)
+	cw.sinceBytes += uint64(n)
+	if cw.sinceBytes >= checkpointByteInterval {
+		// total reflects exactly the bytes just written, including those
+		// the hasher chained in front of cw.w has already hashed, so the
+		// persisted BytesWritten and HasherState never disagree.
+		_ = cw.d.persistCheckpoint(cw.total)
+		cw.sinceBytes = 0
+	}
+	return n, err
+}
+
+func (cw *checkpointingWriter) Count() uint64 {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.total
+}
+
+// checkIn persists a checkpoint if total has advanced past last, holding the
+// same mutex Write does so it never reads hasher state concurrently with a
+// hasher write. It returns the current total for the caller's next call.
+func (cw *checkpointingWriter) checkIn(last uint64) uint64 {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.total != last {
+		_ = cw.d.persistCheckpoint(cw.total)
+	}
+	return cw.total
+}
+
+// flush persists a checkpoint unconditionally, regardless of sinceBytes.
+// run calls it only when it returns an error (including cancellation),
+// after checkpointLoop has exited, so a crash mid-transfer always leaves a
+// checkpoint behind instead of depending on a byte-interval or ticker
+// trigger having already landed. On success it is not called, since the
+// sidecar has already been removed.
+func (cw *checkpointingWriter) flush() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.d.persistCheckpoint(cw.total)
+}
+
+// initWriter builds d.writer, resuming from a checkpoint sidecar when one is
+// present and still valid for the current server state.
+func (d *downloader) initWriter(ctx context.Context) error {
+	if d.checkpointPath == "" {
+		d.writer = datacounter.NewWriterCounter(d.tmpWriter)
+		return nil
+	}
+
+	seeker, ok := d.destWriter.(io.Seeker)
+	if !ok {
+		return NonRetryableWrapf("WithCheckpoint requires a seekable destination")
+	}
+
+	resumeBytes, err := d.resumeFromCheckpoint(ctx, seeker)
+	if err != nil {
+		return err
+	}
+	cw := newCheckpointingWriter(d, d.tmpWriter, resumeBytes)
+	d.tmpWriter = cw
+	d.writer = cw
+	return nil
+}
+
+// resumeFromCheckpoint reads the sidecar file, if any, and validates it
+// against the server's current ETag/Last-Modified with a conditional
+// If-Range request. It returns the number of bytes already written to the
+// destination that can be trusted, seeking the destination to that offset.
+// A stale or mismatched checkpoint results in a fresh start: the sidecar is
+// removed and the destination truncated.
+func (d *downloader) resumeFromCheckpoint(ctx context.Context, seeker io.Seeker) (uint64, error) {
+	data, err := os.ReadFile(d.checkpointPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil || state.URL != d.url {
+		return d.restartFromScratch(seeker)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return 0, NonRetryableWrapf("create checkpoint validation request: %w", err)
+	}
+	for hKey, hValue := range d.headers {
+		req.Header.Set(hKey, hValue)
+	}
+	// A single-byte range is enough to validate freshness via If-Range
+	// without re-downloading the bytes we already have.
+	req.Header.Set(rangeHeader, fmt.Sprintf("bytes=%d-%d", state.BytesWritten, state.BytesWritten))
+	if state.ETag != "" {
+		req.Header.Set("If-Range", state.ETag)
+	} else if state.LastModified != "" {
+		req.Header.Set("If-Range", state.LastModified)
+	} else {
+		return d.restartFromScratch(seeker)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("do checkpoint validation request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusPartialContent {
+		// The server sent us the full file (If-Range mismatch) or something
+		// unexpected; the checkpoint is no longer trustworthy.
+		return d.restartFromScratch(seeker)
+	}
+
+	if _, err := seeker.Seek(int64(state.BytesWritten), io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek destination to resume offset: %w", err)
+	}
+	d.contentLength = state.ContentLength
+	d.checkpointETag = state.ETag
+	d.checkpointLastModified = state.LastModified
+	if len(state.HasherState) > 0 && d.checkpointHasher != nil {
+		if unmarshaler, ok := d.checkpointHasher.(encoding.BinaryUnmarshaler); ok {
+			if err := unmarshaler.UnmarshalBinary(state.HasherState); err != nil {
+				return d.restartFromScratch(seeker)
+			}
+		}
+	}
+	return state.BytesWritten, nil
+}
+
+// restartFromScratch discards a stale checkpoint: it truncates the
+// destination back to empty and removes the sidecar file.
+func (d *downloader) restartFromScratch(seeker io.Seeker) (uint64, error) {
+	if truncater, ok := seeker.(interface{ Truncate(int64) error }); ok {
+		if err := truncater.Truncate(0); err != nil {
+			return 0, fmt.Errorf("truncate stale destination: %w", err)
+		}
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek destination to start: %w", err)
+	}
+	if err := os.Remove(d.checkpointPath); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("remove stale checkpoint: %w", err)
+	}
+	return 0, nil
+}
+
+// checkpointLoop persists progress every checkpointTimeInterval, when any
+// bytes were written since its last persist, until ctx is done. The
+// checkpointByteInterval trigger lives in checkpointingWriter.Write.
+func (d *downloader) checkpointLoop(ctx context.Context) {
+	cw, ok := d.writer.(*checkpointingWriter)
+	if !ok {
+		return
+	}
+
+	t := time.NewTicker(checkpointTimeInterval)
+	defer t.Stop()
+
+	var lastPersisted uint64
+	for {
+		select {
+		case <-t.C:
+			lastPersisted = cw.checkIn(lastPersisted)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// persistCheckpoint atomically writes the given progress to the sidecar
+// file via write-tmp+rename. bytesWritten is passed in by the caller, which
+// must hold checkpointingWriter.mu, rather than read back from d.writer, so
+// it always matches the hasher state captured in the same call.
+func (d *downloader) persistCheckpoint(bytesWritten uint64) error {
+	var hasherState []byte
+	if d.checkpointHasher != nil {
+		if marshaler, ok := d.checkpointHasher.(encoding.BinaryMarshaler); ok {
+			if hs, err := marshaler.MarshalBinary(); err == nil {
+				hasherState = hs
+			}
+		}
+	}
+
+	state := checkpointState{
+		URL:           d.url,
+		ContentLength: d.contentLength,
+		ETag:          d.checkpointETag,
+		LastModified:  d.checkpointLastModified,
+		BytesWritten:  bytesWritten,
+		HasherState:   hasherState,
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+
+	tmpPath := d.checkpointPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("write checkpoint tmp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, d.checkpointPath); err != nil {
+		return fmt.Errorf("rename checkpoint tmp file: %w", err)
+	}
+	return nil
+}
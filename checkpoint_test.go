@@ -0,0 +1,125 @@
+package dlpipe
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/test-go/testify/require"
+)
+
+// TestCheckpointedResumeAcrossProcessRestarts simulates a crash mid-download
+// by canceling the context once a checkpoint has been persisted, then starts
+// a brand new DownloadURL call against the same destination and checkpoint
+// file. It checks that the first attempt leaves a checkpoint and a partial
+// file, and that the second completes with the expected hash and removes
+// the sidecar; it does not check how many bytes the second call fetched.
+func TestCheckpointedResumeAcrossProcessRestarts(t *testing.T) {
+	r := require.New(t)
+
+	serverURL, expectedHash, cleanup := serveInterruptedTestFile(t, fileSize, 0)
+	defer cleanup()
+
+	destFile, err := os.CreateTemp(os.TempDir(), "checkpoint_dest_*.bin")
+	r.NoError(err)
+	destPath := destFile.Name()
+	r.NoError(destFile.Close())
+	defer os.Remove(destPath)
+
+	checkpointPath := destPath + ".checkpoint"
+	defer os.Remove(checkpointPath)
+
+	origInterval := checkpointTimeInterval
+	checkpointTimeInterval = 20 * time.Millisecond
+	defer func() { checkpointTimeInterval = origInterval }()
+
+	// First attempt: throttle the transfer so it can't finish before we
+	// cancel it out from under it, simulating a crash/Ctrl-C.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	f, err := os.OpenFile(destPath, os.O_RDWR, 0o644)
+	r.NoError(err)
+
+	err = DownloadURL(ctx, serverURL, f,
+		WithBandwidthLimit(200*1024),
+		WithCheckpoint(checkpointPath),
+		WithExpectedHash(sha256.New(), expectedHash),
+	)
+	r.Error(err)
+	r.True(errors.Is(err, context.Canceled))
+	r.NoError(f.Close())
+
+	info, err := os.Stat(checkpointPath)
+	r.NoError(err)
+	r.True(info.Size() > 0)
+
+	partial, err := os.Stat(destPath)
+	r.NoError(err)
+	r.True(partial.Size() > 0)
+	r.True(partial.Size() < int64(fileSize))
+
+	// Second attempt: a fresh process opening the same destination should
+	// resume from the checkpoint instead of starting over.
+	f2, err := os.OpenFile(destPath, os.O_RDWR, 0o644)
+	r.NoError(err)
+	defer f2.Close()
+
+	hasher := sha256.New()
+	err = DownloadURL(context.Background(), serverURL, f2,
+		WithExpectedHash(hasher, expectedHash),
+		WithCheckpoint(checkpointPath),
+	)
+	r.NoError(err)
+
+	_, err = os.Stat(checkpointPath)
+	r.True(os.IsNotExist(err), "checkpoint sidecar should be removed on success")
+}
+
+// TestCheckpointedWriteAndTickerPersistConcurrently drives tiny
+// checkpointByteInterval and checkpointTimeInterval values against an
+// unthrottled, >8 MiB transfer so Write-triggered and ticker-triggered
+// persists contend on the checkpoint hasher as much as possible. Run with
+// -race: it catches an unsynchronized hasher.MarshalBinary racing
+// hasher.Write that a throttled, infrequent-tick test like
+// TestCheckpointedResumeAcrossProcessRestarts is too sparse to trigger.
+func TestCheckpointedWriteAndTickerPersistConcurrently(t *testing.T) {
+	r := require.New(t)
+
+	serverURL, expectedHash, cleanup := serveInterruptedTestFile(t, fileSize, 0)
+	defer cleanup()
+
+	destFile, err := os.CreateTemp(os.TempDir(), "checkpoint_race_dest_*.bin")
+	r.NoError(err)
+	destPath := destFile.Name()
+	r.NoError(destFile.Close())
+	defer os.Remove(destPath)
+
+	checkpointPath := destPath + ".checkpoint"
+	defer os.Remove(checkpointPath)
+
+	origByteInterval := checkpointByteInterval
+	origTimeInterval := checkpointTimeInterval
+	checkpointByteInterval = 64 * 1024
+	checkpointTimeInterval = time.Millisecond
+	defer func() {
+		checkpointByteInterval = origByteInterval
+		checkpointTimeInterval = origTimeInterval
+	}()
+
+	f, err := os.OpenFile(destPath, os.O_RDWR, 0o644)
+	r.NoError(err)
+	defer f.Close()
+
+	err = DownloadURL(context.Background(), serverURL, f,
+		WithCheckpoint(checkpointPath),
+		WithExpectedHash(sha256.New(), expectedHash),
+	)
+	r.NoError(err)
+}
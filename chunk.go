@@ -0,0 +1,254 @@
+package dlpipe
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// WithConcurrency enables parallel chunked downloading: the file is split into
+// chunkSize byte ranges and fetched by n workers using HTTP Range requests,
+// similar to the buffer mode in Replicate's pget. If the server does not
+// support range requests, DownloadURL transparently falls back to the
+// single-stream code path.
+func WithConcurrency(n int, chunkSize int64) DownloadOpt {
+	return func(d *downloader) {
+		d.concurrency = n
+		d.chunkSize = chunkSize
+	}
+}
+
+// chunkResult is a chunk downloaded into memory, staged for in-order writing.
+type chunkResult struct {
+	start int64
+	data  []byte
+}
+
+// chunkHeap is a min-heap of chunkResults ordered by start offset, so the
+// drain loop can write chunks to the destination writer in order even though
+// they may complete out of order.
+type chunkHeap []chunkResult
+
+func (h chunkHeap) Len() int           { return len(h) }
+func (h chunkHeap) Less(i, j int) bool { return h[i].start < h[j].start }
+func (h chunkHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *chunkHeap) Push(x interface{}) { *h = append(*h, x.(chunkResult)) }
+
+func (h *chunkHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// probeRangeSupport issues a single-byte range request to learn the content
+// length and whether the server honors byte ranges.
+func (d *downloader) probeRangeSupport(ctx context.Context) (contentLength int64, supportsRanges bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return 0, false, NonRetryableWrapf("create probe request: %w", err)
+	}
+	for hKey, hValue := range d.headers {
+		req.Header.Set(hKey, hValue)
+	}
+	req.Header.Set(rangeHeader, "bytes=0-0")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("do probe request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusPartialContent {
+		// Server ignored the range and returned the whole body (or errored);
+		// either way we cannot assume range support.
+		return resp.ContentLength, false, nil
+	}
+
+	var respStart, respEnd, respTotal int64
+	_, err = fmt.Sscanf(
+		strings.ToLower(resp.Header.Get(contentRangeHeader)),
+		"bytes %d-%d/%d",
+		&respStart, &respEnd, &respTotal,
+	)
+	if err != nil {
+		return 0, false, nil
+	}
+	return respTotal, true, nil
+}
+
+// requestRange issues a GET for the inclusive byte range [start, end] and
+// returns the response body, validating that the server honored the range.
+func (d *downloader) requestRange(ctx context.Context, start, end int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return nil, NonRetryableWrapf("create chunk request: %w", err)
+	}
+	for hKey, hValue := range d.headers {
+		req.Header.Set(hKey, hValue)
+	}
+	req.Header.Set(rangeHeader, fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do chunk request: %w", err)
+	}
+	if resp.StatusCode == http.StatusBadGateway {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("bad gateway")
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		_ = resp.Body.Close()
+		return nil, NonRetryableWrapf("unexpected status code on chunk read: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// downloadChunk fetches the inclusive byte range [start, end] into memory,
+// retrying the remainder of the range independently using the downloader's
+// RetryParameters on transient failures.
+func (d *downloader) downloadChunk(ctx context.Context, start, end int64) ([]byte, error) {
+	retry := d.retryParameters
+	buf := bytes.NewBuffer(make([]byte, 0, end-start+1))
+
+	for {
+		body, err := d.requestRange(ctx, start+int64(buf.Len()), end)
+		if err != nil {
+			var nonRetryable ErrNonRetryable
+			if errors.As(err, &nonRetryable) {
+				return nil, err
+			}
+			if waitErr := retry.Wait(ctx, uint64(buf.Len())); waitErr != nil {
+				return nil, waitErr
+			}
+			continue
+		}
+		_, copyErr := io.Copy(buf, body)
+		_ = body.Close()
+		if copyErr == nil {
+			return buf.Bytes(), nil
+		}
+		if waitErr := retry.Wait(ctx, uint64(buf.Len())); waitErr != nil {
+			return nil, waitErr
+		}
+	}
+}
+
+// runParallel downloads [0, contentLength) as fixed-size chunks dispatched to
+// a worker pool sized by d.concurrency. A single drain goroutine pops
+// completed chunks from a min-heap keyed by start offset and writes them to
+// d.writer as soon as the next-in-order chunk is ready, so WithHasher and
+// WithExpectedHash keep working against the in-order stream rather than
+// per-chunk buffers.
+func (d *downloader) runParallel(ctx context.Context, contentLength int64) error {
+	if d.chunkSize <= 0 {
+		return NonRetryableWrapf("WithConcurrency requires a positive chunkSize, got %d", d.chunkSize)
+	}
+
+	d.contentLength = contentLength
+	numChunks := int((contentLength + d.chunkSize - 1) / d.chunkSize)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu         sync.Mutex
+		cond       = sync.NewCond(&mu)
+		pending    chunkHeap
+		nextOffset int64
+		drainErr   error
+	)
+	heap.Init(&pending)
+
+	// slots bounds the number of chunks that may be downloaded into memory
+	// at once (in flight or buffered awaiting in-order drain) to roughly
+	// 2x concurrency, so a stalled drain can't let unbounded chunk data
+	// pile up on the heap.
+	slots := make(chan struct{}, d.concurrency*2)
+
+	// Wake the drain loop promptly on cancellation, since it otherwise only
+	// wakes on cond.Signal/Broadcast from arriving chunks.
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		cond.Broadcast()
+		mu.Unlock()
+	}()
+
+	drainDone := make(chan struct{})
+	go func() {
+		defer close(drainDone)
+		for nextOffset < contentLength {
+			mu.Lock()
+			for (len(pending) == 0 || pending[0].start != nextOffset) && ctx.Err() == nil {
+				cond.Wait()
+			}
+			if ctx.Err() != nil {
+				mu.Unlock()
+				return
+			}
+			chunk := heap.Pop(&pending).(chunkResult)
+			mu.Unlock()
+
+			if _, err := d.writer.Write(chunk.data); err != nil {
+				drainErr = fmt.Errorf("write chunk at offset %d: %w", chunk.start, err)
+				cancel()
+				return
+			}
+			nextOffset += int64(len(chunk.data))
+			<-slots
+		}
+	}()
+
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(d.concurrency)
+
+	for i := 0; i < numChunks; i++ {
+		start := int64(i) * d.chunkSize
+		end := start + d.chunkSize - 1
+		if end > contentLength-1 {
+			end = contentLength - 1
+		}
+		g.Go(func() error {
+			select {
+			case slots <- struct{}{}:
+			case <-gCtx.Done():
+				return gCtx.Err()
+			}
+
+			data, err := d.downloadChunk(gCtx, start, end)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			heap.Push(&pending, chunkResult{start: start, data: data})
+			cond.Broadcast()
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	if err != nil {
+		// A worker failed: the drain loop can never see every chunk it's
+		// waiting on, so cancel it rather than block forever.
+		cancel()
+	}
+	<-drainDone
+
+	if err != nil {
+		return err
+	}
+	return drainErr
+}
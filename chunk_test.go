@@ -0,0 +1,133 @@
+package dlpipe
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/test-go/testify/require"
+)
+
+func TestParallelDownloadWithRangeSupport(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	serverURL, expectedHash, cleanup := serveInterruptedTestFile(t, fileSize, 0)
+	defer cleanup()
+
+	hasher := sha256.New()
+	err := DownloadURL(ctx, serverURL, io.Discard,
+		WithConcurrency(4, fileSize/10),
+		WithExpectedHash(hasher, expectedHash),
+	)
+	r.NoError(err)
+}
+
+// serveFullTestFile is like serveInterruptedTestFile but always answers with
+// the full body and a 200, ignoring any Range header, simulating a server
+// that does not support range requests.
+func serveFullTestFile(t *testing.T, body []byte) (serverURL string, cleanup func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		log.Printf("Serving full file (size: %d), ignoring Range: %s", len(body), req.Header.Get(rangeHeader))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	})
+	server := httptest.NewServer(mux)
+	return server.URL, server.Close
+}
+
+func TestParallelDownloadFallsBackWhenRangesUnsupported(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	body := make([]byte, fileSize)
+	hasher := sha256.New()
+	_, err := hasher.Write(body)
+	r.NoError(err)
+	expectedHash := hasher.Sum(nil)
+
+	serverURL, cleanup := serveFullTestFile(t, body)
+	defer cleanup()
+
+	err = DownloadURL(ctx, serverURL, io.Discard,
+		WithConcurrency(4, fileSize/10),
+		WithExpectedHash(sha256.New(), expectedHash),
+	)
+	r.NoError(err)
+}
+
+func TestRunParallelRejectsZeroChunkSize(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	serverURL, _, cleanup := serveInterruptedTestFile(t, fileSize, 0)
+	defer cleanup()
+
+	err := DownloadURL(ctx, serverURL, io.Discard, WithConcurrency(4, 0))
+	r.Error(err)
+	var nonRetryable ErrNonRetryable
+	r.True(errors.As(err, &nonRetryable))
+}
+
+// TestDownloadChunkRetriesTransientRequestErrors guards against a
+// regression where downloadChunk gave up on the first requestRange error
+// instead of retrying it like it already did for a failed io.Copy.
+func TestDownloadChunkRetriesTransientRequestErrors(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const payload = "hello world"
+	client := &scriptedHTTPClient{
+		responses: []func(*http.Request) (*http.Response, error){
+			func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("connection reset")
+			},
+			func(*http.Request) (*http.Response, error) {
+				return newPartialResponse(0, int64(len(payload)-1), int64(len(payload)), []byte(payload)), nil
+			},
+		},
+	}
+
+	d := &downloader{
+		url:             "http://example.invalid/file",
+		httpClient:      client,
+		retryParameters: fastRetryParameters(),
+	}
+
+	data, err := d.downloadChunk(ctx, 0, int64(len(payload)-1))
+	r.NoError(err)
+	r.Equal(payload, string(data))
+}
+
+// TestDownloadChunkFailsFastOnNonRetryableRequestError guards the other
+// half of the same fix: a non-retryable requestRange error (e.g. an
+// unexpected status code) must not be retried.
+func TestDownloadChunkFailsFastOnNonRetryableRequestError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	client := &scriptedHTTPClient{
+		responses: []func(*http.Request) (*http.Response, error){
+			func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: io.NopCloser(nil)}, nil
+			},
+		},
+	}
+
+	d := &downloader{
+		url:             "http://example.invalid/file",
+		httpClient:      client,
+		retryParameters: fastRetryParameters(),
+	}
+
+	_, err := d.downloadChunk(ctx, 0, 9)
+	r.Error(err)
+	var nonRetryable ErrNonRetryable
+	r.True(errors.As(err, &nonRetryable))
+}
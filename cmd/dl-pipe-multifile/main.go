@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	dlpipe "github.com/zeta-chain/dl-pipe"
+)
+
+// manifestFile mirrors dlpipe.Manifest for JSON decoding, with ExpectedHash
+// expressed as a hex string since manifests are authored by hand.
+type manifestFile struct {
+	Entries []struct {
+		URL          string            `json:"url"`
+		Destination  string            `json:"destination"`
+		ExpectedHash string            `json:"expected_hash"`
+		Headers      map[string]string `json:"headers"`
+	} `json:"entries"`
+}
+
+func loadManifest(path string) (dlpipe.Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dlpipe.Manifest{}, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var mf manifestFile
+	if err := json.Unmarshal(data, &mf); err != nil {
+		return dlpipe.Manifest{}, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	manifest := dlpipe.Manifest{Entries: make([]dlpipe.ManifestEntry, 0, len(mf.Entries))}
+	for _, e := range mf.Entries {
+		var expectedHash []byte
+		if e.ExpectedHash != "" {
+			expectedHash, err = hex.DecodeString(e.ExpectedHash)
+			if err != nil {
+				return dlpipe.Manifest{}, fmt.Errorf("invalid expected_hash for %s: %w", e.URL, err)
+			}
+		}
+		manifest.Entries = append(manifest.Entries, dlpipe.ManifestEntry{
+			URL:          e.URL,
+			Destination:  e.Destination,
+			ExpectedHash: expectedHash,
+			Headers:      e.Headers,
+		})
+	}
+	return manifest, nil
+}
+
+const progressFuncInterval = time.Second * 10
+
+func getProgressOpt(progress bool) dlpipe.GetterOpt {
+	if !progress {
+		return nil
+	}
+	return dlpipe.WithMultiProgressFunc(func(stats dlpipe.MultiProgressStats) {
+		fmt.Fprintf(os.Stderr, "%s: %s of %s (overall %s of %s)\n",
+			stats.URL,
+			humanize.Bytes(stats.FileCurrent), humanize.Bytes(stats.FileTotal),
+			humanize.Bytes(stats.OverallCurrent), humanize.Bytes(stats.OverallTotal),
+		)
+	}, progressFuncInterval)
+}
+
+func main() {
+	var maxConcurrentFiles int
+	var progress bool
+	flag.IntVar(&maxConcurrentFiles, "max-concurrent-files", 20, "Maximum number of files to download concurrently")
+	flag.BoolVar(&progress, "progress", false, "Show download progress")
+	flag.Parse()
+
+	manifestPath := flag.Arg(0)
+	if manifestPath == "" {
+		fmt.Fprintf(os.Stderr, "manifest path is required\n")
+		os.Exit(1)
+	}
+
+	manifest, err := loadManifest(manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	getter := dlpipe.NewGetter(
+		dlpipe.WithMaxConcurrentFiles(maxConcurrentFiles),
+		getProgressOpt(progress),
+	)
+
+	if err := getter.Download(context.Background(), manifest); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -69,19 +69,14 @@ func getHashOpt(hashArg string) dlpipe.DownloadOpt {
 
 const progressFuncInterval = time.Second * 10
 
-func getProgressFunc() dlpipe.ProgressFunc {
-	prevLength := uint64(0)
-	return func(currentLength uint64, totalLength uint64) {
-		currentLengthStr := humanize.Bytes(currentLength)
-		totalLengthStr := humanize.Bytes(totalLength)
-
-		rate := float64(currentLength-prevLength) / progressFuncInterval.Seconds()
-		rateStr := humanize.Bytes(uint64(rate))
-		prevLength = currentLength
-
-		percent := float64(currentLength) / float64(totalLength) * 100
-
-		fmt.Fprintf(os.Stderr, "Downloaded %s of %s (%.1f%%) at %s/s\n", currentLengthStr, totalLengthStr, percent, rateStr)
+func getProgressStatsFunc() dlpipe.ProgressStatsFunc {
+	return func(stats dlpipe.ProgressStats) {
+		percent := float64(stats.Current) / float64(stats.Total) * 100
+
+		fmt.Fprintf(os.Stderr, "Downloaded %s of %s (%.1f%%) at %s/s, ETA %s\n",
+			humanize.Bytes(stats.Current), humanize.Bytes(stats.Total), percent,
+			humanize.Bytes(uint64(stats.InstantRate)), stats.ETA.Round(time.Second),
+		)
 	}
 }
 
@@ -89,7 +84,7 @@ func getProgressOpt(progress bool) dlpipe.DownloadOpt {
 	if !progress {
 		return nil
 	}
-	return dlpipe.WithProgressFunc(getProgressFunc(), progressFuncInterval)
+	return dlpipe.WithProgressStatsFunc(getProgressStatsFunc(), progressFuncInterval)
 }
 
 func main() {
@@ -7,10 +7,11 @@ import (
 	"hash"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
-	"github.com/miolini/datacounter"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -22,7 +23,15 @@ const (
 
 type DownloadOpt func(*downloader)
 
-func WithHTTPClient(client *http.Client) DownloadOpt {
+// HTTPClient is the subset of *http.Client that the downloader depends on.
+// It exists so transport-level behavior (partial reads, 502s, malformed
+// Content-Range) can be exercised in tests with a fake client instead of a
+// real network round trip.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+func WithHTTPClient(client HTTPClient) DownloadOpt {
 	return func(d *downloader) {
 		d.httpClient = client
 	}
@@ -31,6 +40,7 @@ func WithHTTPClient(client *http.Client) DownloadOpt {
 func WithHasher(hasher hash.Hash) DownloadOpt {
 	return func(d *downloader) {
 		d.tmpWriter = io.MultiWriter(d.tmpWriter, hasher)
+		d.checkpointHasher = hasher
 	}
 }
 
@@ -38,6 +48,7 @@ func WithHasher(hasher hash.Hash) DownloadOpt {
 func WithExpectedHash(hasher hash.Hash, expected []byte) DownloadOpt {
 	return func(d *downloader) {
 		d.tmpWriter = io.MultiWriter(d.tmpWriter, hasher)
+		d.checkpointHasher = hasher
 		d.finalFuncs = append(d.finalFuncs, func() error {
 			givenHash := hasher.Sum(nil)
 			if !bytes.Equal(givenHash, expected) {
@@ -59,6 +70,8 @@ func WithHeaders(headers map[string]string) DownloadOpt {
 
 type ProgressFunc func(currentLength uint64, totalLength uint64)
 
+// WithProgressFunc reports current/total bytes at the given interval. See
+// also WithProgressStatsFunc, which additionally reports rate and ETA.
 func WithProgressFunc(progressFunc ProgressFunc, interval time.Duration) DownloadOpt {
 	return func(d *downloader) {
 		d.progressFunc = progressFunc
@@ -72,6 +85,65 @@ func WithRetryParameters(params RetryParameters) DownloadOpt {
 	}
 }
 
+// minBandwidthLimitBurst floors the limiter's burst so a low bytesPerSecond
+// cap doesn't shrink it below a single io.Copy buffer (32 KiB); limitedWriter
+// additionally slices any write larger than the burst, so a large
+// WithConcurrency chunkSize never exceeds it either.
+const minBandwidthLimitBurst = 32 * oneKB
+
+const oneKB = 1 << 10
+
+// WithBandwidthLimit caps the effective download rate to bytesPerSecond.
+// The limiter is shared across resume attempts within one DownloadURL call
+// (and, in parallel chunk mode, across every chunk written to the
+// destination) so the aggregate bandwidth is bounded, not the bandwidth of
+// any single stream.
+func WithBandwidthLimit(bytesPerSecond int64) DownloadOpt {
+	return func(d *downloader) {
+		burst := bytesPerSecond
+		if burst < minBandwidthLimitBurst {
+			burst = minBandwidthLimitBurst
+		}
+		d.bandwidthLimiter = rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
+	}
+}
+
+// limitedWriter throttles writes through a shared rate.Limiter, waiting for
+// enough tokens before delegating to the wrapped writer. Canceling ctx
+// unblocks a waiting Write promptly. Writes larger than the limiter's burst
+// are split into burst-sized slices, since WaitN errors if asked to wait for
+// more than Burst tokens at once (e.g. a WithConcurrency chunk bigger than
+// the configured bytesPerSecond).
+type limitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	burst := lw.limiter.Burst()
+	var written int
+	for len(p) > 0 {
+		n := len(p)
+		if burst > 0 && n > burst {
+			n = burst
+		}
+		if err := lw.limiter.WaitN(lw.ctx, n); err != nil {
+			return written, err
+		}
+		wrote, err := lw.w.Write(p[:n])
+		written += wrote
+		if err != nil {
+			return written, err
+		}
+		if wrote < n {
+			return written, io.ErrShortWrite
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
 type RetryParameters struct {
 	MaxRetries     int
 	BaseWait       time.Duration
@@ -112,35 +184,40 @@ func DefaultRetryParameters() RetryParameters {
 	}
 }
 
+// writeCounter is the subset of *datacounter.WriterCounter the downloader
+// depends on. Resuming from a checkpoint needs a counter that starts from a
+// nonzero offset, which datacounter.WriterCounter cannot do, so the field is
+// kept as an interface rather than the concrete type.
+type writeCounter interface {
+	io.Writer
+	Count() uint64
+}
+
 type downloader struct {
 	// these fields are set once
-	url             string
-	writer          *datacounter.WriterCounter
-	httpClient      *http.Client
-	retryParameters RetryParameters
+	url              string
+	destWriter       io.Writer
+	writer           writeCounter
+	httpClient       HTTPClient
+	retryParameters  RetryParameters
+	concurrency      int
+	chunkSize        int64
+	bandwidthLimiter *rate.Limiter
+	checkpointPath   string
+	checkpointHasher hash.Hash
 
 	// these fields are used by option functions
-	tmpWriter        io.Writer
-	finalFuncs       []func() error
-	headers          map[string]string
-	progressFunc     ProgressFunc
-	progressInterval time.Duration
+	tmpWriter         io.Writer
+	finalFuncs        []func() error
+	headers           map[string]string
+	progressFunc      ProgressFunc
+	progressStatsFunc ProgressStatsFunc
+	progressInterval  time.Duration
 
 	// these fields are updated at runtime
-	contentLength int64
-}
-
-func (d *downloader) progressReportLoop(ctx context.Context) {
-	t := time.NewTicker(d.progressInterval)
-	defer t.Stop()
-	for {
-		select {
-		case <-t.C:
-			d.progressFunc(d.writer.Count(), uint64(d.contentLength))
-		case <-ctx.Done():
-			return
-		}
-	}
+	contentLength          int64
+	checkpointETag         string
+	checkpointLastModified string
 }
 
 func (d *downloader) runInner(ctx context.Context) (io.ReadCloser, error) {
@@ -167,6 +244,8 @@ func (d *downloader) runInner(ctx context.Context) (io.ReadCloser, error) {
 	}
 	if d.contentLength == 0 {
 		d.contentLength = resp.ContentLength
+		d.checkpointETag = resp.Header.Get("ETag")
+		d.checkpointLastModified = resp.Header.Get("Last-Modified")
 
 		if resp.StatusCode != http.StatusOK {
 			return nil, NonRetryableWrapf("unexpected status code on first read: %d", resp.StatusCode)
@@ -205,14 +284,60 @@ func (d *downloader) runInner(ctx context.Context) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
-func (d *downloader) run(ctx context.Context) error {
+func (d *downloader) run(ctx context.Context) (err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	if d.progressFunc != nil {
+
+	if err = d.initWriter(ctx); err != nil {
+		return err
+	}
+
+	if d.checkpointPath != "" {
+		checkpointDone := make(chan struct{})
+		go func() {
+			defer close(checkpointDone)
+			d.checkpointLoop(ctx)
+		}()
+		// However run returns, stop the ticker and join it first. On
+		// success runFinalFuncs has already removed the sidecar file; only
+		// persist one final, unconditional checkpoint on the error path, so
+		// a crash or cancellation mid-transfer is never left with a stale
+		// or missing sidecar file just because no byte-interval or ticker
+		// trigger happened to land first.
+		defer func() {
+			cancel()
+			<-checkpointDone
+			if err != nil {
+				if cw, ok := d.writer.(*checkpointingWriter); ok {
+					_ = cw.flush()
+				}
+			}
+		}()
+	}
+
+	if d.progressFunc != nil || d.progressStatsFunc != nil {
 		go d.progressReportLoop(ctx)
 	}
+
+	if d.concurrency > 1 && d.writer.Count() == 0 {
+		var contentLength int64
+		var supportsRanges bool
+		contentLength, supportsRanges, err = d.probeRangeSupport(ctx)
+		if err != nil {
+			return err
+		}
+		if supportsRanges && contentLength > 0 {
+			if err = d.runParallel(ctx, contentLength); err != nil {
+				return err
+			}
+			return d.runFinalFuncs()
+		}
+		// Server doesn't support ranges; fall through to the single-stream path.
+	}
+
 	for {
-		body, err := d.runInner(ctx)
+		var body io.ReadCloser
+		body, err = d.runInner(ctx)
 		if err != nil {
 			return err
 		}
@@ -227,18 +352,28 @@ func (d *downloader) run(ctx context.Context) error {
 		}
 	}
 
+	return d.runFinalFuncs()
+}
+
+func (d *downloader) runFinalFuncs() error {
 	for _, finalFunc := range d.finalFuncs {
 		if err := finalFunc(); err != nil {
 			return err
 		}
 	}
+	if d.checkpointPath != "" {
+		if err := os.Remove(d.checkpointPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove checkpoint: %w", err)
+		}
+	}
 	return nil
 }
 
 func DownloadURL(ctx context.Context, url string, writer io.Writer, opts ...DownloadOpt) error {
 	d := &downloader{
-		url:       url,
-		tmpWriter: writer,
+		url:        url,
+		destWriter: writer,
+		tmpWriter:  writer,
 		httpClient: &http.Client{
 			Transport: &http.Transport{
 				IdleConnTimeout:       10 * time.Second,
@@ -253,6 +388,11 @@ func DownloadURL(ctx context.Context, url string, writer io.Writer, opts ...Down
 		}
 		opt(d)
 	}
-	d.writer = datacounter.NewWriterCounter(d.tmpWriter)
+	if d.bandwidthLimiter != nil {
+		// Wrap last, after WithHasher/WithExpectedHash, so every byte that
+		// reaches the destination (across every resume attempt within this
+		// call) passes through the same shared limiter.
+		d.tmpWriter = &limitedWriter{ctx: ctx, w: d.tmpWriter, limiter: d.bandwidthLimiter}
+	}
 	return d.run(ctx)
 }
@@ -0,0 +1,172 @@
+package dlpipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/test-go/testify/require"
+)
+
+// scriptedHTTPClient returns successive responses from a fixed script,
+// letting tests drive runInner's resume branches deterministically without
+// a real network round trip.
+type scriptedHTTPClient struct {
+	responses []func(*http.Request) (*http.Response, error)
+	calls     int
+}
+
+func (c *scriptedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if c.calls >= len(c.responses) {
+		return nil, errors.New("scriptedHTTPClient: ran out of scripted responses")
+	}
+	resp, err := c.responses[c.calls](req)
+	c.calls++
+	return resp, err
+}
+
+// flakyBody returns data successfully for the first failAfter bytes, then
+// fails, simulating a connection drop mid-copy.
+type flakyBody struct {
+	data      []byte
+	pos       int
+	failAfter int
+}
+
+func (b *flakyBody) Read(p []byte) (int, error) {
+	if b.pos >= b.failAfter {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, b.data[b.pos:b.failAfter])
+	b.pos += n
+	return n, nil
+}
+
+func (b *flakyBody) Close() error { return nil }
+
+func newFullResponse(body []byte) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		Body:          &flakyBody{data: body, failAfter: len(body)},
+		ContentLength: int64(len(body)),
+	}
+}
+
+func newPartialResponse(start, end, total int64, body []byte) *http.Response {
+	resp := &http.Response{
+		StatusCode: http.StatusPartialContent,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+	resp.Header.Set(contentRangeHeader, fmt.Sprintf("bytes %d-%d/%d", start, end, total))
+	return resp
+}
+
+func fastRetryParameters() RetryParameters {
+	return RetryParameters{
+		MaxRetries:     3,
+		BaseWait:       time.Millisecond,
+		WaitMultiplier: 1,
+	}
+}
+
+// TestRunInnerReturnsBadGatewayOnResume drops the first response mid-body,
+// so the download retries with a resume request; that request gets a 502,
+// and the bad gateway error is what DownloadURL returns.
+func TestRunInnerReturnsBadGatewayOnResume(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const payload = "hello world"
+	client := &scriptedHTTPClient{
+		responses: []func(*http.Request) (*http.Response, error){
+			func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{},
+					Body:          &flakyBody{data: []byte(payload), failAfter: 5},
+					ContentLength: int64(len(payload)),
+				}, nil
+			},
+			func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			},
+		},
+	}
+
+	err := DownloadURL(ctx, "http://example.invalid/file", io.Discard,
+		WithHTTPClient(client),
+		WithRetryParameters(fastRetryParameters()),
+	)
+	r.Error(err)
+	r.Contains(err.Error(), "bad gateway")
+}
+
+func TestRunInnerRejectsMismatchedRangeStart(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const payload = "hello world"
+	client := &scriptedHTTPClient{
+		responses: []func(*http.Request) (*http.Response, error){
+			func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{},
+					Body:          &flakyBody{data: []byte(payload), failAfter: 5},
+					ContentLength: int64(len(payload)),
+				}, nil
+			},
+			func(*http.Request) (*http.Response, error) {
+				// Server responds starting at the wrong offset (2 instead of 5).
+				return newPartialResponse(2, int64(len(payload)-1), int64(len(payload)), []byte(payload[5:])), nil
+			},
+		},
+	}
+
+	err := DownloadURL(ctx, "http://example.invalid/file", io.Discard,
+		WithHTTPClient(client),
+		WithRetryParameters(fastRetryParameters()),
+	)
+	r.Error(err)
+	var nonRetryable ErrNonRetryable
+	r.True(errors.As(err, &nonRetryable))
+	r.Contains(err.Error(), "unexpected response range start")
+}
+
+func TestRunInnerRejectsUnexpectedStatusOnResume(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const payload = "hello world"
+	client := &scriptedHTTPClient{
+		responses: []func(*http.Request) (*http.Response, error){
+			func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{},
+					Body:          &flakyBody{data: []byte(payload), failAfter: 5},
+					ContentLength: int64(len(payload)),
+				}, nil
+			},
+			func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+			},
+		},
+	}
+
+	err := DownloadURL(ctx, "http://example.invalid/file", io.Discard,
+		WithHTTPClient(client),
+		WithRetryParameters(fastRetryParameters()),
+	)
+	r.Error(err)
+	var nonRetryable ErrNonRetryable
+	r.True(errors.As(err, &nonRetryable))
+	r.Contains(err.Error(), "unexpected status code on subsequent read")
+}
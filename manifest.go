@@ -0,0 +1,179 @@
+package dlpipe
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultMaxConcurrentFiles is used when WithMaxConcurrentFiles is not given.
+const defaultMaxConcurrentFiles = 20
+
+// ManifestEntry describes a single file to fetch as part of a batch download.
+type ManifestEntry struct {
+	URL          string
+	Destination  string
+	ExpectedHash []byte
+	Headers      map[string]string
+}
+
+// Manifest is a batch of files to download together.
+type Manifest struct {
+	Entries []ManifestEntry
+}
+
+// MultiProgressStats reports progress for one file alongside the running
+// total across the whole manifest.
+type MultiProgressStats struct {
+	URL            string
+	FileCurrent    uint64
+	FileTotal      uint64
+	OverallCurrent uint64
+	OverallTotal   uint64
+}
+
+// MultiProgressFunc is called periodically while a Manifest is downloading.
+type MultiProgressFunc func(stats MultiProgressStats)
+
+type GetterOpt func(*Getter)
+
+// WithMaxConcurrentFiles bounds how many manifest entries download at once.
+// Combined with per-file concurrency (see WithConcurrency), total in-flight
+// requests are bounded by maxConcurrentFiles * perFileConcurrency.
+func WithMaxConcurrentFiles(n int) GetterOpt {
+	return func(g *Getter) {
+		g.maxConcurrentFiles = n
+	}
+}
+
+// WithFileDownloadOpts applies the given DownloadOpts to every file in the
+// manifest, e.g. to enable WithConcurrency or WithRetryParameters for all
+// transfers.
+func WithFileDownloadOpts(opts ...DownloadOpt) GetterOpt {
+	return func(g *Getter) {
+		g.fileOpts = opts
+	}
+}
+
+// WithMultiProgressFunc reports aggregate progress across the manifest,
+// combining per-file and overall bytes, at the given interval.
+func WithMultiProgressFunc(fn MultiProgressFunc, interval time.Duration) GetterOpt {
+	return func(g *Getter) {
+		g.progressFunc = fn
+		g.progressInterval = interval
+	}
+}
+
+// Getter downloads a Manifest of files concurrently.
+type Getter struct {
+	maxConcurrentFiles int
+	fileOpts           []DownloadOpt
+	progressFunc       MultiProgressFunc
+	progressInterval   time.Duration
+}
+
+// NewGetter builds a Getter with the given options applied over sane
+// defaults (20 concurrent files).
+func NewGetter(opts ...GetterOpt) *Getter {
+	g := &Getter{
+		maxConcurrentFiles: defaultMaxConcurrentFiles,
+	}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(g)
+	}
+	return g
+}
+
+// fileProgress tracks the last known progress for one manifest entry.
+type fileProgress struct {
+	current uint64
+	total   uint64
+}
+
+// Download fetches every entry in the manifest, bounded by
+// WithMaxConcurrentFiles concurrent files. On any non-retryable error it
+// cancels the remaining transfers and returns the first error, mirroring
+// the fix in pget's Strategy.Wait().
+func (g *Getter) Download(ctx context.Context, manifest Manifest) error {
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(g.maxConcurrentFiles)
+
+	var (
+		mu       sync.Mutex
+		progress = make(map[string]*fileProgress, len(manifest.Entries))
+	)
+	for _, entry := range manifest.Entries {
+		progress[entry.URL] = &fileProgress{}
+	}
+
+	overallProgress := func() (current, total uint64) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range progress {
+			current += p.current
+			total += p.total
+		}
+		return current, total
+	}
+
+	for _, entry := range manifest.Entries {
+		entry := entry
+		eg.Go(func() error {
+			return g.downloadOne(egCtx, entry, &mu, progress[entry.URL], overallProgress)
+		})
+	}
+
+	return eg.Wait()
+}
+
+func (g *Getter) downloadOne(
+	ctx context.Context,
+	entry ManifestEntry,
+	mu *sync.Mutex,
+	fileProg *fileProgress,
+	overallProgress func() (uint64, uint64),
+) error {
+	f, err := os.Create(entry.Destination)
+	if err != nil {
+		return NonRetryableWrapf("create destination %s: %w", entry.Destination, err)
+	}
+	defer f.Close()
+
+	opts := append([]DownloadOpt{}, g.fileOpts...)
+	if len(entry.Headers) > 0 {
+		opts = append(opts, WithHeaders(entry.Headers))
+	}
+	if len(entry.ExpectedHash) > 0 {
+		opts = append(opts, WithExpectedHash(sha256.New(), entry.ExpectedHash))
+	}
+	if g.progressFunc != nil {
+		opts = append(opts, WithProgressFunc(func(current, total uint64) {
+			mu.Lock()
+			fileProg.current = current
+			fileProg.total = total
+			mu.Unlock()
+
+			overallCurrent, overallTotal := overallProgress()
+			g.progressFunc(MultiProgressStats{
+				URL:            entry.URL,
+				FileCurrent:    current,
+				FileTotal:      total,
+				OverallCurrent: overallCurrent,
+				OverallTotal:   overallTotal,
+			})
+		}, g.progressInterval))
+	}
+
+	if err := DownloadURL(ctx, entry.URL, f, opts...); err != nil {
+		return fmt.Errorf("download %s: %w", entry.URL, err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package dlpipe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/test-go/testify/require"
+)
+
+func TestGetterDownloadManifest(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const numFiles = 5
+	bodies := make([][]byte, numFiles)
+	hashes := make([][]byte, numFiles)
+
+	mux := http.NewServeMux()
+	for i := 0; i < numFiles; i++ {
+		body := []byte(fmt.Sprintf("payload for manifest file %d", i))
+		bodies[i] = body
+		hasher := sha256.New()
+		_, err := hasher.Write(body)
+		r.NoError(err)
+		hashes[i] = hasher.Sum(nil)
+
+		mux.HandleFunc(fmt.Sprintf("/file-%d", i), func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write(body)
+		})
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	destDir := t.TempDir()
+	entries := make([]ManifestEntry, numFiles)
+	for i := 0; i < numFiles; i++ {
+		entries[i] = ManifestEntry{
+			URL:          server.URL + fmt.Sprintf("/file-%d", i),
+			Destination:  filepath.Join(destDir, fmt.Sprintf("file-%d.bin", i)),
+			ExpectedHash: hashes[i],
+		}
+	}
+
+	getter := NewGetter()
+	r.NoError(getter.Download(ctx, Manifest{Entries: entries}))
+
+	for i, entry := range entries {
+		data, err := os.ReadFile(entry.Destination)
+		r.NoError(err)
+		r.Equal(bodies[i], data)
+	}
+}
+
+// TestGetterDownloadRespectsMaxConcurrentFiles drives more manifest entries
+// than WithMaxConcurrentFiles allows and asserts the number of simultaneously
+// in-flight requests never exceeds the configured bound.
+func TestGetterDownloadRespectsMaxConcurrentFiles(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const numFiles = 6
+	const maxConcurrent = 2
+
+	var active, peak int32
+	body := []byte("x")
+
+	mux := http.NewServeMux()
+	for i := 0; i < numFiles; i++ {
+		mux.HandleFunc(fmt.Sprintf("/file-%d", i), func(w http.ResponseWriter, req *http.Request) {
+			n := atomic.AddInt32(&active, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			_, _ = w.Write(body)
+			atomic.AddInt32(&active, -1)
+		})
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	destDir := t.TempDir()
+	entries := make([]ManifestEntry, numFiles)
+	for i := 0; i < numFiles; i++ {
+		entries[i] = ManifestEntry{
+			URL:         server.URL + fmt.Sprintf("/file-%d", i),
+			Destination: filepath.Join(destDir, fmt.Sprintf("file-%d.bin", i)),
+		}
+	}
+
+	getter := NewGetter(WithMaxConcurrentFiles(maxConcurrent))
+	r.NoError(getter.Download(ctx, Manifest{Entries: entries}))
+
+	r.True(atomic.LoadInt32(&peak) <= int32(maxConcurrent))
+}
+
+// slowWriteHandler writes body in small chunks with a delay between each, so
+// progress reporting has multiple ticks to observe instead of the whole
+// response landing in one shot.
+func slowWriteHandler(body []byte, chunkSize int, delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		// Set Content-Length explicitly so the chunked Flush calls below
+		// don't hide the file's total size from the downloader.
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		flusher, _ := w.(http.Flusher)
+		for i := 0; i < len(body); i += chunkSize {
+			end := i + chunkSize
+			if end > len(body) {
+				end = len(body)
+			}
+			_, _ = w.Write(body[i:end])
+			if flusher != nil {
+				flusher.Flush()
+			}
+			time.Sleep(delay)
+		}
+	}
+}
+
+// TestGetterDownloadReportsAggregateProgress checks that WithMultiProgressFunc
+// combines per-file progress into a running OverallCurrent/OverallTotal
+// across the whole manifest, not just the file that happens to report.
+func TestGetterDownloadReportsAggregateProgress(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const numFiles = 3
+	body := bytes.Repeat([]byte{'a'}, 2000)
+
+	mux := http.NewServeMux()
+	for i := 0; i < numFiles; i++ {
+		mux.HandleFunc(fmt.Sprintf("/file-%d", i), slowWriteHandler(body, 200, 5*time.Millisecond))
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	destDir := t.TempDir()
+	entries := make([]ManifestEntry, numFiles)
+	for i := 0; i < numFiles; i++ {
+		entries[i] = ManifestEntry{
+			URL:         server.URL + fmt.Sprintf("/file-%d", i),
+			Destination: filepath.Join(destDir, fmt.Sprintf("file-%d.bin", i)),
+		}
+	}
+
+	var (
+		mu           sync.Mutex
+		calls        int
+		maxOverall   uint64
+		overallTotal uint64
+	)
+	progressFn := func(stats MultiProgressStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if stats.OverallCurrent > maxOverall {
+			maxOverall = stats.OverallCurrent
+		}
+		overallTotal = stats.OverallTotal
+	}
+
+	getter := NewGetter(
+		WithMaxConcurrentFiles(numFiles),
+		WithMultiProgressFunc(progressFn, 5*time.Millisecond),
+	)
+	r.NoError(getter.Download(ctx, Manifest{Entries: entries}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	r.True(calls > 1, "expected more than one progress callback, got %d", calls)
+	r.Equal(uint64(numFiles*len(body)), overallTotal)
+	r.True(maxOverall > 0 && maxOverall <= overallTotal)
+}
+
+// TestGetterDownloadCancelsSiblingsOnFirstError verifies a non-retryable
+// failure on one entry cancels the rest of the manifest instead of letting
+// Download block until every sibling finishes on its own.
+func TestGetterDownloadCancelsSiblingsOnFirstError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const slowTimeout = 5 * time.Second
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bad", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(slowTimeout):
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	destDir := t.TempDir()
+	entries := []ManifestEntry{
+		{URL: server.URL + "/bad", Destination: filepath.Join(destDir, "bad.bin")},
+		{URL: server.URL + "/slow", Destination: filepath.Join(destDir, "slow.bin")},
+	}
+
+	getter := NewGetter(WithMaxConcurrentFiles(len(entries)))
+
+	start := time.Now()
+	err := getter.Download(ctx, Manifest{Entries: entries})
+	elapsed := time.Since(start)
+
+	r.Error(err)
+	var nonRetryable ErrNonRetryable
+	r.True(errors.As(err, &nonRetryable))
+	r.True(elapsed < slowTimeout, "the slow sibling should be canceled well before its %s timeout, took %s", slowTimeout, elapsed)
+}
@@ -0,0 +1,101 @@
+package dlpipe
+
+import (
+	"context"
+	"time"
+)
+
+// progressEWMAAlpha weights the instant rate in the exponentially-weighted
+// moving average used for ProgressStats.InstantRate; at ~0.3 a handful of
+// ticks of history smooth out brief stalls or bursts without lagging too far
+// behind a sustained change in rate.
+const progressEWMAAlpha = 0.3
+
+// ProgressStats is a ready-to-render snapshot of download progress, handed
+// to a ProgressStatsFunc instead of forcing every caller to re-derive rate
+// and ETA from raw byte counts.
+type ProgressStats struct {
+	Current uint64
+	Total   uint64
+
+	// InstantRate is an EWMA of the per-tick rate, smoothing out brief
+	// stalls or bursts so the displayed speed doesn't jitter tick to tick.
+	InstantRate float64
+	// AverageRate is bytes/sec averaged over the whole download so far
+	// (Current/Elapsed). ETA is derived from this, not InstantRate, so a
+	// momentary speed change doesn't swing the estimate wildly.
+	AverageRate float64
+
+	ETA     time.Duration
+	Elapsed time.Duration
+}
+
+// ProgressStatsFunc is called periodically while a download runs.
+type ProgressStatsFunc func(ProgressStats)
+
+// WithProgressStatsFunc reports ProgressStats at the given interval,
+// including a smoothed rate and ETA. It can be combined with
+// WithProgressFunc, which keeps receiving plain current/total bytes.
+func WithProgressStatsFunc(statsFunc ProgressStatsFunc, interval time.Duration) DownloadOpt {
+	return func(d *downloader) {
+		d.progressStatsFunc = statsFunc
+		d.progressInterval = interval
+	}
+}
+
+func (d *downloader) progressReportLoop(ctx context.Context) {
+	t := time.NewTicker(d.progressInterval)
+	defer t.Stop()
+
+	start := time.Now()
+	lastTick := start
+	var lastCount, instantRate float64
+
+	for {
+		select {
+		case <-t.C:
+			now := time.Now()
+			current := d.writer.Count()
+			total := uint64(d.contentLength)
+
+			var tickRate float64
+			if elapsed := now.Sub(lastTick).Seconds(); elapsed > 0 {
+				tickRate = (float64(current) - lastCount) / elapsed
+			}
+			if lastTick == start {
+				instantRate = tickRate
+			} else {
+				instantRate = progressEWMAAlpha*tickRate + (1-progressEWMAAlpha)*instantRate
+			}
+
+			var averageRate float64
+			if elapsedTotal := now.Sub(start).Seconds(); elapsedTotal > 0 {
+				averageRate = float64(current) / elapsedTotal
+			}
+
+			var eta time.Duration
+			if averageRate > 0 && total > current {
+				eta = time.Duration(float64(total-current) / averageRate * float64(time.Second))
+			}
+
+			if d.progressFunc != nil {
+				d.progressFunc(current, total)
+			}
+			if d.progressStatsFunc != nil {
+				d.progressStatsFunc(ProgressStats{
+					Current:     current,
+					Total:       total,
+					InstantRate: instantRate,
+					AverageRate: averageRate,
+					ETA:         eta,
+					Elapsed:     now.Sub(start),
+				})
+			}
+
+			lastTick = now
+			lastCount = float64(current)
+		case <-ctx.Done():
+			return
+		}
+	}
+}